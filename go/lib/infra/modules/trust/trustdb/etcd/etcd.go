@@ -0,0 +1,217 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package etcd implements a trustdb.TrustDB backed by an etcd cluster, so
+// that multiple control-service replicas can share TRC/cert/key state
+// without a shared filesystem.
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// tableNames lists the key namespaces backed up/restored by Tables.
+var tableNames = []string{"trcs", "chains", "keys"}
+
+// Config configures the etcd-backed trust database.
+type Config struct {
+	// Endpoints is the list of etcd cluster endpoints to connect to.
+	Endpoints []string
+	// KeyPrefix is prepended to every key this backend writes.
+	KeyPrefix string
+	// DialTimeout bounds how long to wait for the initial connection.
+	DialTimeout time.Duration
+	// CertFile, KeyFile and CAFile configure mutual TLS towards etcd. If
+	// none is set, the connection is not encrypted; otherwise CertFile and
+	// KeyFile must both be set, and CAFile is optional (the host's root CAs
+	// are used if it is unset).
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Backend is an etcd-backed trust database.
+type Backend struct {
+	client *clientv3.Client
+	prefix string
+}
+
+var _ trustdb.TrustDB = (*Backend)(nil)
+
+// New connects to the etcd cluster described by cfg.
+func New(cfg Config) (*Backend, error) {
+	tlsConfig, err := loadTLS(cfg)
+	if err != nil {
+		return nil, serrors.WrapStr("loading etcd TLS config", err)
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		DialTimeout: cfg.DialTimeout,
+		TLS:         tlsConfig,
+	})
+	if err != nil {
+		return nil, serrors.WrapStr("connecting to etcd", err, "endpoints", cfg.Endpoints)
+	}
+	return &Backend{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+func loadTLS(cfg Config) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" && cfg.CAFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, serrors.WrapStr("loading client certificate", err)
+	}
+	var pool *x509.CertPool
+	if cfg.CAFile != "" {
+		pool = x509.NewCertPool()
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, serrors.WrapStr("reading CA file", err, "file", cfg.CAFile)
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, serrors.New("invalid CA file", "file", cfg.CAFile)
+		}
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool}, nil
+}
+
+// Close closes the underlying etcd client.
+func (b *Backend) Close() error {
+	return b.client.Close()
+}
+
+func (b *Backend) key(parts ...string) string {
+	return b.prefix + strings.Join(parts, "/")
+}
+
+// InsertTRC implements trustdb.TrustDB.
+func (b *Backend) InsertTRC(ctx context.Context, isd string, version int, raw []byte) (bool, error) {
+	_, err := b.client.Put(ctx, b.key("trcs", isd, strconv.Itoa(version)), string(raw))
+	if err != nil {
+		return false, serrors.WrapStr("putting TRC", err, "isd", isd, "version", version)
+	}
+	return true, nil
+}
+
+// GetTRC implements trustdb.TrustDB.
+func (b *Backend) GetTRC(ctx context.Context, isd string, version int) ([]byte, error) {
+	resp, err := b.client.Get(ctx, b.key("trcs", isd, strconv.Itoa(version)))
+	if err != nil {
+		return nil, serrors.WrapStr("getting TRC", err, "isd", isd, "version", version)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, serrors.New("TRC not found", "isd", isd, "version", version)
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+// InsertChain implements trustdb.TrustDB.
+func (b *Backend) InsertChain(ctx context.Context, ia string, raw []byte) (bool, error) {
+	_, err := b.client.Put(ctx, b.key("chains", ia), string(raw))
+	if err != nil {
+		return false, serrors.WrapStr("putting chain", err, "ia", ia)
+	}
+	return true, nil
+}
+
+// GetChain implements trustdb.TrustDB.
+func (b *Backend) GetChain(ctx context.Context, ia string) ([]byte, error) {
+	resp, err := b.client.Get(ctx, b.key("chains", ia))
+	if err != nil {
+		return nil, serrors.WrapStr("getting chain", err, "ia", ia)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, serrors.New("chain not found", "ia", ia)
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+// InsertKey implements trustdb.TrustDB.
+func (b *Backend) InsertKey(ctx context.Context, ia string, usage string, raw []byte) error {
+	_, err := b.client.Put(ctx, b.key("keys", ia, usage), string(raw))
+	if err != nil {
+		return serrors.WrapStr("putting key", err, "ia", ia, "usage", usage)
+	}
+	return nil
+}
+
+// GetKey implements trustdb.TrustDB.
+func (b *Backend) GetKey(ctx context.Context, ia string, usage string) ([]byte, error) {
+	resp, err := b.client.Get(ctx, b.key("keys", ia, usage))
+	if err != nil {
+		return nil, serrors.WrapStr("getting key", err, "ia", ia, "usage", usage)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, serrors.New("key not found", "ia", ia, "usage", usage)
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+// Tables implements the snapshot interface consumed by
+// go/lib/truststorage's Backup/Restore/Migrate.
+func (b *Backend) Tables(ctx context.Context) ([]string, error) {
+	return tableNames, nil
+}
+
+// DumpTable returns every key-value pair stored under table, with the
+// table/prefix portion of the etcd key stripped so the resulting
+// trustdb.KVRecord keys match the sub-keys used by the other backends
+// (e.g. "<ia>/<usage>"), not etcd's own on-the-wire key shape.
+func (b *Backend) DumpTable(ctx context.Context, table string) ([][]byte, error) {
+	tablePrefix := b.key(table) + "/"
+	resp, err := b.client.Get(ctx, tablePrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, serrors.WrapStr("dumping table", err, "table", table)
+	}
+	records := make([][]byte, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		record, err := trustdb.MarshalKVRecord(string(kv.Key[len(tablePrefix):]), kv.Value)
+		if err != nil {
+			return nil, serrors.WrapStr("encoding record", err, "table", table)
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// LoadTable replaces every key-value pair stored under table with
+// records, re-adding the table/prefix portion DumpTable stripped.
+func (b *Backend) LoadTable(ctx context.Context, table string, records [][]byte) error {
+	if _, err := b.client.Delete(ctx, b.key(table)+"/", clientv3.WithPrefix()); err != nil {
+		return serrors.WrapStr("clearing table", err, "table", table)
+	}
+	for _, record := range records {
+		key, value, err := trustdb.UnmarshalKVRecord(record)
+		if err != nil {
+			return serrors.WrapStr("decoding record", err, "table", table)
+		}
+		if _, err := b.client.Put(ctx, b.key(table, key), string(value)); err != nil {
+			return serrors.WrapStr("loading record", err, "table", table)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mongo implements a trustdb.TrustDB backed by a MongoDB instance.
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// tableNames lists the logical tables backed up/restored by Tables.
+var tableNames = []string{"trcs", "chains", "keys"}
+
+// Config configures the mongodb-backed trust database.
+type Config struct {
+	// URI is the mongodb connection URI, e.g. "mongodb://localhost:27017".
+	URI string
+	// Database is the name of the database the trust collection lives in.
+	Database string
+	// Collection is the name of the collection trust material is stored in.
+	Collection string
+}
+
+// doc is the document shape backing every table; Table scopes the
+// (otherwise flat) key space, mirroring the key-prefix approach used by
+// the etcd backend.
+type doc struct {
+	Table string `bson:"table"`
+	Key   string `bson:"key"`
+	Value []byte `bson:"value"`
+}
+
+// Backend is a mongodb-backed trust database.
+type Backend struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+var _ trustdb.TrustDB = (*Backend)(nil)
+
+// New connects to the mongodb instance described by cfg.
+func New(cfg Config) (*Backend, error) {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		return nil, serrors.WrapStr("connecting to mongodb", err, "uri", cfg.URI)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, serrors.WrapStr("pinging mongodb", err, "uri", cfg.URI)
+	}
+	coll := client.Database(cfg.Database).Collection(cfg.Collection)
+	return &Backend{client: client, coll: coll}, nil
+}
+
+// Close disconnects the underlying mongodb client.
+func (b *Backend) Close() error {
+	return b.client.Disconnect(context.Background())
+}
+
+func (b *Backend) put(ctx context.Context, table, key string, value []byte) error {
+	_, err := b.coll.UpdateOne(ctx,
+		bson.M{"table": table, "key": key},
+		bson.M{"$set": doc{Table: table, Key: key, Value: value}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (b *Backend) get(ctx context.Context, table, key string) ([]byte, error) {
+	var d doc
+	if err := b.coll.FindOne(ctx, bson.M{"table": table, "key": key}).Decode(&d); err != nil {
+		return nil, err
+	}
+	return d.Value, nil
+}
+
+// InsertTRC implements trustdb.TrustDB.
+func (b *Backend) InsertTRC(ctx context.Context, isd string, version int, raw []byte) (bool, error) {
+	key := fmt.Sprintf("%s/%d", isd, version)
+	if err := b.put(ctx, "trcs", key, raw); err != nil {
+		return false, serrors.WrapStr("inserting TRC", err, "isd", isd, "version", version)
+	}
+	return true, nil
+}
+
+// GetTRC implements trustdb.TrustDB.
+func (b *Backend) GetTRC(ctx context.Context, isd string, version int) ([]byte, error) {
+	key := fmt.Sprintf("%s/%d", isd, version)
+	raw, err := b.get(ctx, "trcs", key)
+	if err != nil {
+		return nil, serrors.WrapStr("fetching TRC", err, "isd", isd, "version", version)
+	}
+	return raw, nil
+}
+
+// InsertChain implements trustdb.TrustDB.
+func (b *Backend) InsertChain(ctx context.Context, ia string, raw []byte) (bool, error) {
+	if err := b.put(ctx, "chains", ia, raw); err != nil {
+		return false, serrors.WrapStr("inserting chain", err, "ia", ia)
+	}
+	return true, nil
+}
+
+// GetChain implements trustdb.TrustDB.
+func (b *Backend) GetChain(ctx context.Context, ia string) ([]byte, error) {
+	raw, err := b.get(ctx, "chains", ia)
+	if err != nil {
+		return nil, serrors.WrapStr("fetching chain", err, "ia", ia)
+	}
+	return raw, nil
+}
+
+// InsertKey implements trustdb.TrustDB.
+func (b *Backend) InsertKey(ctx context.Context, ia string, usage string, raw []byte) error {
+	key := ia + "/" + usage
+	if err := b.put(ctx, "keys", key, raw); err != nil {
+		return serrors.WrapStr("inserting key", err, "ia", ia, "usage", usage)
+	}
+	return nil
+}
+
+// GetKey implements trustdb.TrustDB.
+func (b *Backend) GetKey(ctx context.Context, ia string, usage string) ([]byte, error) {
+	key := ia + "/" + usage
+	raw, err := b.get(ctx, "keys", key)
+	if err != nil {
+		return nil, serrors.WrapStr("fetching key", err, "ia", ia, "usage", usage)
+	}
+	return raw, nil
+}
+
+// Tables implements the snapshot interface consumed by
+// go/lib/truststorage's Backup/Restore/Migrate.
+func (b *Backend) Tables(ctx context.Context) ([]string, error) {
+	return tableNames, nil
+}
+
+// DumpTable returns every key-value pair stored under table.
+func (b *Backend) DumpTable(ctx context.Context, table string) ([][]byte, error) {
+	cur, err := b.coll.Find(ctx, bson.M{"table": table})
+	if err != nil {
+		return nil, serrors.WrapStr("dumping table", err, "table", table)
+	}
+	defer cur.Close(ctx)
+	var records [][]byte
+	for cur.Next(ctx) {
+		var d doc
+		if err := cur.Decode(&d); err != nil {
+			return nil, serrors.WrapStr("decoding document", err, "table", table)
+		}
+		record, err := trustdb.MarshalKVRecord(d.Key, d.Value)
+		if err != nil {
+			return nil, serrors.WrapStr("encoding record", err, "table", table)
+		}
+		records = append(records, record)
+	}
+	return records, cur.Err()
+}
+
+// LoadTable replaces every key-value pair stored under table with records.
+func (b *Backend) LoadTable(ctx context.Context, table string, records [][]byte) error {
+	if _, err := b.coll.DeleteMany(ctx, bson.M{"table": table}); err != nil {
+		return serrors.WrapStr("clearing table", err, "table", table)
+	}
+	for _, record := range records {
+		key, value, err := trustdb.UnmarshalKVRecord(record)
+		if err != nil {
+			return serrors.WrapStr("decoding record", err, "table", table)
+		}
+		if err := b.put(ctx, table, key, value); err != nil {
+			return serrors.WrapStr("loading record", err, "table", table)
+		}
+	}
+	return nil
+}
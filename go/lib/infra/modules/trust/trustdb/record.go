@@ -0,0 +1,44 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustdb
+
+import "encoding/json"
+
+// KVRecord is the JSON-encoded snapshot record every backend (sqlite,
+// etcd, mongodb) uses to implement the Tables/DumpTable/LoadTable trio
+// that go/lib/truststorage's Backup/Restore/Migrate rely on. Key is the
+// backend-agnostic sub-key identifying the row within its table (e.g.
+// "<isd>/<version>" for trcs, "<ia>/<usage>" for keys); the table itself
+// is conveyed out of band via the table argument to DumpTable/LoadTable,
+// not encoded in Key. Using the same shape everywhere is what lets a
+// snapshot taken from one backend be restored into another.
+type KVRecord struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// MarshalKVRecord encodes a single (key, value) pair as a snapshot record.
+func MarshalKVRecord(key string, value []byte) ([]byte, error) {
+	return json.Marshal(KVRecord{Key: key, Value: value})
+}
+
+// UnmarshalKVRecord decodes a snapshot record produced by MarshalKVRecord.
+func UnmarshalKVRecord(record []byte) (string, []byte, error) {
+	var r KVRecord
+	if err := json.Unmarshal(record, &r); err != nil {
+		return "", nil, err
+	}
+	return r.Key, r.Value, nil
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// Tables returns the tables backed up/restored by DumpTable/LoadTable.
+func (b *Backend) Tables(ctx context.Context) ([]string, error) {
+	return tableNames, nil
+}
+
+// DumpTable returns every row of table, encoded one trustdb.KVRecord per
+// row. This is the same encoding the etcd and mongodb backends use, so a
+// snapshot taken here can be restored into either of them, and vice versa.
+func (b *Backend) DumpTable(ctx context.Context, table string) ([][]byte, error) {
+	switch table {
+	case "trcs":
+		return b.dumpTRCs(ctx)
+	case "chains":
+		return b.dumpChains(ctx)
+	case "keys":
+		return b.dumpKeys(ctx)
+	default:
+		return nil, serrors.New("unknown table", "table", table)
+	}
+}
+
+// LoadTable replaces the content of table with records, as produced by a
+// prior call to DumpTable on this or another backend.
+func (b *Backend) LoadTable(ctx context.Context, table string, records [][]byte) error {
+	switch table {
+	case "trcs":
+		return b.loadTRCs(ctx, records)
+	case "chains":
+		return b.loadChains(ctx, records)
+	case "keys":
+		return b.loadKeys(ctx, records)
+	default:
+		return serrors.New("unknown table", "table", table)
+	}
+}
+
+// trcKey and parseTRCKey convert between the (isd, version) pair and the
+// "isd/version" KVRecord key used by every backend.
+func trcKey(isd string, version int) string {
+	return isd + "/" + strconv.Itoa(version)
+}
+
+func parseTRCKey(key string) (string, int, error) {
+	isd, versionStr, ok := splitKey(key)
+	if !ok {
+		return "", 0, serrors.New("malformed trc record key", "key", key)
+	}
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		return "", 0, serrors.WrapStr("parsing trc version", err, "key", key)
+	}
+	return isd, version, nil
+}
+
+// keyKey and parseKeyKey convert between the (ia, usage) pair and the
+// "ia/usage" KVRecord key used by every backend.
+func keyKey(ia, usage string) string {
+	return ia + "/" + usage
+}
+
+func parseKeyKey(key string) (string, string, error) {
+	ia, usage, ok := splitKey(key)
+	if !ok {
+		return "", "", serrors.New("malformed key record key", "key", key)
+	}
+	return ia, usage, nil
+}
+
+func splitKey(key string) (string, string, bool) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func (b *Backend) dumpTRCs(ctx context.Context) ([][]byte, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT isd, version, raw FROM trcs`)
+	if err != nil {
+		return nil, serrors.WrapStr("dumping trcs", err)
+	}
+	defer rows.Close()
+	var records [][]byte
+	for rows.Next() {
+		var isd string
+		var version int
+		var raw []byte
+		if err := rows.Scan(&isd, &version, &raw); err != nil {
+			return nil, serrors.WrapStr("scanning trc row", err)
+		}
+		record, err := trustdb.MarshalKVRecord(trcKey(isd, version), raw)
+		if err != nil {
+			return nil, serrors.WrapStr("encoding trc record", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// loadTRCs replaces the content of trcs within a single transaction, so a
+// failure partway through (a malformed record, a cancelled context)
+// leaves the table as it was rather than half-cleared.
+func (b *Backend) loadTRCs(ctx context.Context, records [][]byte) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return serrors.WrapStr("starting trcs transaction", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trcs`); err != nil {
+		return serrors.WrapStr("clearing trcs", err)
+	}
+	for _, record := range records {
+		key, raw, err := trustdb.UnmarshalKVRecord(record)
+		if err != nil {
+			return serrors.WrapStr("decoding trc record", err)
+		}
+		isd, version, err := parseTRCKey(key)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO trcs (isd, version, raw) VALUES (?, ?, ?)`,
+			isd, version, raw); err != nil {
+			return serrors.WrapStr("inserting trc", err, "isd", isd, "version", version)
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *Backend) dumpChains(ctx context.Context) ([][]byte, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT ia, raw FROM chains`)
+	if err != nil {
+		return nil, serrors.WrapStr("dumping chains", err)
+	}
+	defer rows.Close()
+	var records [][]byte
+	for rows.Next() {
+		var ia string
+		var raw []byte
+		if err := rows.Scan(&ia, &raw); err != nil {
+			return nil, serrors.WrapStr("scanning chain row", err)
+		}
+		record, err := trustdb.MarshalKVRecord(ia, raw)
+		if err != nil {
+			return nil, serrors.WrapStr("encoding chain record", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// loadChains replaces the content of chains within a single transaction;
+// see loadTRCs.
+func (b *Backend) loadChains(ctx context.Context, records [][]byte) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return serrors.WrapStr("starting chains transaction", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chains`); err != nil {
+		return serrors.WrapStr("clearing chains", err)
+	}
+	for _, record := range records {
+		ia, raw, err := trustdb.UnmarshalKVRecord(record)
+		if err != nil {
+			return serrors.WrapStr("decoding chain record", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO chains (ia, raw) VALUES (?, ?)`, ia, raw); err != nil {
+			return serrors.WrapStr("inserting chain", err, "ia", ia)
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *Backend) dumpKeys(ctx context.Context) ([][]byte, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT ia, usage, raw FROM keys`)
+	if err != nil {
+		return nil, serrors.WrapStr("dumping keys", err)
+	}
+	defer rows.Close()
+	var records [][]byte
+	for rows.Next() {
+		var ia, usage string
+		var raw []byte
+		if err := rows.Scan(&ia, &usage, &raw); err != nil {
+			return nil, serrors.WrapStr("scanning key row", err)
+		}
+		record, err := trustdb.MarshalKVRecord(keyKey(ia, usage), raw)
+		if err != nil {
+			return nil, serrors.WrapStr("encoding key record", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// loadKeys replaces the content of keys within a single transaction; see
+// loadTRCs.
+func (b *Backend) loadKeys(ctx context.Context, records [][]byte) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return serrors.WrapStr("starting keys transaction", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM keys`); err != nil {
+		return serrors.WrapStr("clearing keys", err)
+	}
+	for _, record := range records {
+		key, raw, err := trustdb.UnmarshalKVRecord(record)
+		if err != nil {
+			return serrors.WrapStr("decoding key record", err)
+		}
+		ia, usage, err := parseKeyKey(key)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO keys (ia, usage, raw) VALUES (?, ?, ?)`,
+			ia, usage, raw); err != nil {
+			return serrors.WrapStr("inserting key", err, "ia", ia, "usage", usage)
+		}
+	}
+	return tx.Commit()
+}
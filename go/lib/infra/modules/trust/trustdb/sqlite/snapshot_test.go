@@ -0,0 +1,121 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb/sqlite"
+)
+
+// TestDumpTableUsesSharedKVRecordKeys checks that DumpTable encodes rows
+// with the same backend-agnostic sub-key shape ("<isd>/<version>" for
+// trcs, "<ia>" for chains, "<ia>/<usage>" for keys) that the etcd and
+// mongodb backends use, rather than a sqlite-specific row shape. This is
+// what allows go/lib/truststorage's Migrate/Restore to move a snapshot
+// between backend types without silently dropping fields.
+func TestDumpTableUsesSharedKVRecordKeys(t *testing.T) {
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = db.InsertTRC(ctx, "1", 2, []byte("trc-raw"))
+	require.NoError(t, err)
+	_, err = db.InsertChain(ctx, "1-ff00:0:110", []byte("chain-raw"))
+	require.NoError(t, err)
+	require.NoError(t, db.InsertKey(ctx, "1-ff00:0:110", "decrypt", []byte("key-raw")))
+
+	trcs, err := db.DumpTable(ctx, "trcs")
+	require.NoError(t, err)
+	require.Len(t, trcs, 1)
+	key, value, err := trustdb.UnmarshalKVRecord(trcs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "1/2", key)
+	assert.Equal(t, []byte("trc-raw"), value)
+
+	chains, err := db.DumpTable(ctx, "chains")
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+	key, value, err = trustdb.UnmarshalKVRecord(chains[0])
+	require.NoError(t, err)
+	assert.Equal(t, "1-ff00:0:110", key)
+	assert.Equal(t, []byte("chain-raw"), value)
+
+	keys, err := db.DumpTable(ctx, "keys")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	key, value, err = trustdb.UnmarshalKVRecord(keys[0])
+	require.NoError(t, err)
+	assert.Equal(t, "1-ff00:0:110/decrypt", key)
+	assert.Equal(t, []byte("key-raw"), value)
+}
+
+// TestLoadTableAcceptsForeignKVRecords checks that LoadTable accepts
+// records encoded the way a different backend (e.g. etcd or mongodb)
+// would encode them, not just records produced by this package's own
+// DumpTable.
+func TestLoadTableAcceptsForeignKVRecords(t *testing.T) {
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	trc, err := trustdb.MarshalKVRecord("1/3", []byte("foreign-trc"))
+	require.NoError(t, err)
+	require.NoError(t, db.LoadTable(ctx, "trcs", [][]byte{trc}))
+	raw, err := db.GetTRC(ctx, "1", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("foreign-trc"), raw)
+
+	key, err := trustdb.MarshalKVRecord("1-ff00:0:110/sign", []byte("foreign-key"))
+	require.NoError(t, err)
+	require.NoError(t, db.LoadTable(ctx, "keys", [][]byte{key}))
+	raw, err = db.GetKey(ctx, "1-ff00:0:110", "sign")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("foreign-key"), raw)
+}
+
+// TestLoadTableRollsBackOnError checks that a failure partway through
+// LoadTable (here, a malformed record after a valid one) leaves the
+// table as it was before the call, rather than half-cleared: LoadTable
+// runs inside a single transaction per table.
+func TestLoadTableRollsBackOnError(t *testing.T) {
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = db.InsertTRC(ctx, "1", 1, []byte("original"))
+	require.NoError(t, err)
+
+	good, err := trustdb.MarshalKVRecord("1/2", []byte("new"))
+	require.NoError(t, err)
+	bad := []byte("not a valid KVRecord")
+	require.Error(t, db.LoadTable(ctx, "trcs", [][]byte{good, bad}))
+
+	raw, err := db.GetTRC(ctx, "1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("original"), raw)
+
+	_, err = db.GetTRC(ctx, "1", 2)
+	assert.Error(t, err, "the partial load must have been rolled back")
+}
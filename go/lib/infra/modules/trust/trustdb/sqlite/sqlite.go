@@ -0,0 +1,206 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite implements a sqlite-backed trustdb.TrustDB, optionally
+// encrypted at rest via the SQLCipher pragmas exposed by the
+// go-sqlcipher driver. The driver is fully backwards compatible with
+// plain, unencrypted sqlite databases, so both New and NewEncrypted share
+// the same on-disk format and schema; only the PRAGMA key (and friends)
+// executed by NewEncrypted right after opening the connection differ.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	// Registers the "sqlite3" driver. Unlike mattn/go-sqlite3, this driver
+	// understands the SQLCipher PRAGMAs used by NewEncrypted.
+	_ "github.com/mutecomm/go-sqlcipher/v4"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS trcs (
+	isd TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	raw BLOB NOT NULL,
+	PRIMARY KEY (isd, version)
+);
+CREATE TABLE IF NOT EXISTS chains (
+	ia TEXT NOT NULL PRIMARY KEY,
+	raw BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS keys (
+	ia TEXT NOT NULL,
+	usage TEXT NOT NULL,
+	raw BLOB NOT NULL,
+	PRIMARY KEY (ia, usage)
+);
+`
+
+// tableNames lists the tables backed up/restored by Backend.Tables.
+var tableNames = []string{"trcs", "chains", "keys"}
+
+// Backend is a sqlite-backed trust database.
+type Backend struct {
+	db *sql.DB
+}
+
+var _ trustdb.TrustDB = (*Backend)(nil)
+
+// New opens (creating if necessary) an unencrypted sqlite trust database
+// at connection.
+func New(connection string) (*Backend, error) {
+	return open(connection, nil)
+}
+
+// EncryptionParams configures the SQLCipher page encryption applied by
+// NewEncrypted.
+type EncryptionParams struct {
+	// Key is the raw encryption key material.
+	Key []byte
+	// KDFIter is the number of KDF iterations used to derive the page
+	// encryption key. Zero leaves the driver default in place.
+	KDFIter int
+	// CipherPageSize is the page size, in bytes, of the encrypted
+	// database. Zero leaves the driver default in place.
+	CipherPageSize int
+}
+
+// NewEncrypted opens (creating if necessary) a sqlite trust database at
+// connection whose pages are transparently AES-256 encrypted using params.
+func NewEncrypted(connection string, params EncryptionParams) (*Backend, error) {
+	return open(connection, &params)
+}
+
+func open(connection string, params *EncryptionParams) (*Backend, error) {
+	db, err := sql.Open("sqlite3", connection)
+	if err != nil {
+		return nil, serrors.WrapStr("opening sqlite database", err, "connection", connection)
+	}
+	if params != nil {
+		if err := applyEncryption(db, *params); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, serrors.WrapStr("initializing schema", err)
+	}
+	return &Backend{db: db}, nil
+}
+
+// applyEncryption executes the SQLCipher PRAGMAs that must run before any
+// other statement on a fresh connection.
+func applyEncryption(db *sql.DB, params EncryptionParams) error {
+	if _, err := db.Exec(fmt.Sprintf(`PRAGMA key = "x'%x'"`, params.Key)); err != nil {
+		return serrors.WrapStr("setting encryption key", err)
+	}
+	if params.KDFIter > 0 {
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA kdf_iter = %d", params.KDFIter)); err != nil {
+			return serrors.WrapStr("setting kdf_iter", err)
+		}
+	}
+	if params.CipherPageSize > 0 {
+		q := fmt.Sprintf("PRAGMA cipher_page_size = %d", params.CipherPageSize)
+		if _, err := db.Exec(q); err != nil {
+			return serrors.WrapStr("setting cipher_page_size", err)
+		}
+	}
+	// Touch the database so that a wrong key is detected now, rather than
+	// on the first caller-issued query.
+	if _, err := db.Exec("SELECT count(*) FROM sqlite_master"); err != nil {
+		return serrors.WrapStr("verifying encryption key", err)
+	}
+	return nil
+}
+
+// SetMaxOpenConns sets the maximum number of open connections to the
+// database.
+func (b *Backend) SetMaxOpenConns(n int) { b.db.SetMaxOpenConns(n) }
+
+// SetMaxIdleConns sets the maximum number of idle connections to the
+// database.
+func (b *Backend) SetMaxIdleConns(n int) { b.db.SetMaxIdleConns(n) }
+
+// Close closes the underlying database connection.
+func (b *Backend) Close() error {
+	return b.db.Close()
+}
+
+// InsertTRC implements trustdb.TrustDB.
+func (b *Backend) InsertTRC(ctx context.Context, isd string, version int, raw []byte) (bool, error) {
+	_, err := b.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO trcs (isd, version, raw) VALUES (?, ?, ?)`, isd, version, raw)
+	if err != nil {
+		return false, serrors.WrapStr("inserting TRC", err, "isd", isd, "version", version)
+	}
+	return true, nil
+}
+
+// GetTRC implements trustdb.TrustDB.
+func (b *Backend) GetTRC(ctx context.Context, isd string, version int) ([]byte, error) {
+	var raw []byte
+	err := b.db.QueryRowContext(ctx,
+		`SELECT raw FROM trcs WHERE isd = ? AND version = ?`, isd, version).Scan(&raw)
+	if err != nil {
+		return nil, serrors.WrapStr("fetching TRC", err, "isd", isd, "version", version)
+	}
+	return raw, nil
+}
+
+// InsertChain implements trustdb.TrustDB.
+func (b *Backend) InsertChain(ctx context.Context, ia string, raw []byte) (bool, error) {
+	_, err := b.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO chains (ia, raw) VALUES (?, ?)`, ia, raw)
+	if err != nil {
+		return false, serrors.WrapStr("inserting chain", err, "ia", ia)
+	}
+	return true, nil
+}
+
+// GetChain implements trustdb.TrustDB.
+func (b *Backend) GetChain(ctx context.Context, ia string) ([]byte, error) {
+	var raw []byte
+	err := b.db.QueryRowContext(ctx, `SELECT raw FROM chains WHERE ia = ?`, ia).Scan(&raw)
+	if err != nil {
+		return nil, serrors.WrapStr("fetching chain", err, "ia", ia)
+	}
+	return raw, nil
+}
+
+// InsertKey implements trustdb.TrustDB.
+func (b *Backend) InsertKey(ctx context.Context, ia string, usage string, raw []byte) error {
+	_, err := b.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO keys (ia, usage, raw) VALUES (?, ?, ?)`, ia, usage, raw)
+	if err != nil {
+		return serrors.WrapStr("inserting key", err, "ia", ia, "usage", usage)
+	}
+	return nil
+}
+
+// GetKey implements trustdb.TrustDB.
+func (b *Backend) GetKey(ctx context.Context, ia string, usage string) ([]byte, error) {
+	var raw []byte
+	err := b.db.QueryRowContext(ctx,
+		`SELECT raw FROM keys WHERE ia = ? AND usage = ?`, ia, usage).Scan(&raw)
+	if err != nil {
+		return nil, serrors.WrapStr("fetching key", err, "ia", ia, "usage", usage)
+	}
+	return raw, nil
+}
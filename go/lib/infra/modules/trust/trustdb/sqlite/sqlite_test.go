@@ -0,0 +1,67 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb/sqlite"
+)
+
+func TestNewInsertAndGet(t *testing.T) {
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.InsertTRC(ctx, "1", 1, []byte("trc-raw"))
+	require.NoError(t, err)
+	raw, err := db.GetTRC(ctx, "1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("trc-raw"), raw)
+}
+
+func TestNewEncryptedRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "encrypted.db")
+	params := sqlite.EncryptionParams{Key: []byte("super-secret-key"), KDFIter: 4000}
+
+	db, err := sqlite.NewEncrypted(path, params)
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = db.InsertChain(ctx, "1-ff00:0:110", []byte("chain-raw"))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Opening the same file with a wrong key must fail; the driver should
+	// not silently fall back to treating it as plaintext.
+	wrongKeyDB, err := sqlite.NewEncrypted(path, sqlite.EncryptionParams{Key: []byte("wrong-key")})
+	if err == nil {
+		defer wrongKeyDB.Close()
+		_, err = wrongKeyDB.GetChain(ctx, "1-ff00:0:110")
+	}
+	assert.Error(t, err)
+
+	reopened, err := sqlite.NewEncrypted(path, params)
+	require.NoError(t, err)
+	defer reopened.Close()
+	raw, err := reopened.GetChain(ctx, "1-ff00:0:110")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chain-raw"), raw)
+}
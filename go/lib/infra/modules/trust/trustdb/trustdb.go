@@ -0,0 +1,47 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trustdb defines the interface implemented by every trust
+// database backend (sqlite, etcd, mongodb, ...), and is consumed by
+// go/lib/truststorage to build a concrete backend from a Config.
+package trustdb
+
+import (
+	"context"
+	"io"
+)
+
+// TrustDB stores TRCs, certificate chains and raw key material. All methods
+// are safe for concurrent use.
+type TrustDB interface {
+	io.Closer
+
+	// InsertTRC inserts, or replaces, the TRC for the given ISD and
+	// version. It returns whether a row was inserted or replaced.
+	InsertTRC(ctx context.Context, isd string, version int, raw []byte) (bool, error)
+	// GetTRC returns the raw TRC for the given ISD and version.
+	GetTRC(ctx context.Context, isd string, version int) ([]byte, error)
+
+	// InsertChain inserts, or replaces, the certificate chain for the
+	// given ISD-AS. It returns whether a row was inserted or replaced.
+	InsertChain(ctx context.Context, ia string, raw []byte) (bool, error)
+	// GetChain returns the raw certificate chain for the given ISD-AS.
+	GetChain(ctx context.Context, ia string) ([]byte, error)
+
+	// InsertKey inserts, or replaces, the raw key material for the given
+	// ISD-AS and usage.
+	InsertKey(ctx context.Context, ia string, usage string, raw []byte) error
+	// GetKey returns the raw key material for the given ISD-AS and usage.
+	GetKey(ctx context.Context, ia string, usage string) ([]byte, error)
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage
+
+import (
+	"strconv"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb/etcd"
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb/mongo"
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb/sqlite"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// backendEntry bundles the validation and construction logic for a single
+// backend. New backends are added by registering an entry in backends
+// below; this is the registry pattern that keeps sqlite the default while
+// treating every other backend, including non-SQL ones, as first-class.
+type backendEntry struct {
+	validate func(cfg *Config) error
+	new      func(cfg *Config) (trustdb.TrustDB, error)
+}
+
+var backends = map[string]backendEntry{
+	BackendSqlite: {
+		validate: func(cfg *Config) error { return cfg.validateSqliteConn() },
+		new: func(cfg *Config) (trustdb.TrustDB, error) {
+			return sqlite.New(cfg.Connection)
+		},
+	},
+	BackendEncryptedSqlite: {
+		validate: func(cfg *Config) error {
+			if err := cfg.validateSqliteConn(); err != nil {
+				return err
+			}
+			return cfg.EncryptedSqlite.Validate()
+		},
+		new: func(cfg *Config) (trustdb.TrustDB, error) {
+			return cfg.newEncrypted()
+		},
+	},
+	BackendEtcd: {
+		validate: func(cfg *Config) error { return cfg.Etcd.Validate() },
+		new: func(cfg *Config) (trustdb.TrustDB, error) {
+			return etcd.New(etcd.Config{
+				Endpoints:   cfg.Etcd.Endpoints,
+				KeyPrefix:   cfg.Etcd.KeyPrefix,
+				DialTimeout: cfg.Etcd.DialTimeout.Duration,
+				CertFile:    cfg.Etcd.CertFile,
+				KeyFile:     cfg.Etcd.KeyFile,
+				CAFile:      cfg.Etcd.CAFile,
+			})
+		},
+	},
+	BackendMongo: {
+		validate: func(cfg *Config) error { return cfg.Mongo.Validate() },
+		new: func(cfg *Config) (trustdb.TrustDB, error) {
+			return mongo.New(mongo.Config{
+				URI:        cfg.Mongo.URI,
+				Database:   cfg.Mongo.Database,
+				Collection: cfg.Mongo.Collection,
+			})
+		},
+	},
+}
+
+// New creates a trust database backend from the config. The backend is
+// selected by cfg.Backend; see the backends registry above for the set of
+// supported backends.
+func (cfg *Config) New() (trustdb.TrustDB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, serrors.WrapStr("validating trust db config", err)
+	}
+	db, err := backends[cfg.Backend].new(cfg)
+	if err != nil {
+		return nil, serrors.WrapStr("connecting to trust db", err, "backend", cfg.Backend)
+	}
+	setConnLimits(db, cfg)
+	return db, nil
+}
+
+// newEncrypted opens the sqlite trust database at cfg.Connection with
+// page-level AES-256 encryption provided by a SQLCipher-compatible driver.
+// The encryption key is loaded from cfg.EncryptedSqlite.KeyFile, never from
+// the TOML configuration itself.
+func (cfg *Config) newEncrypted() (trustdb.TrustDB, error) {
+	key, err := cfg.EncryptedSqlite.LoadKey()
+	if err != nil {
+		return nil, serrors.WrapStr("loading trust db encryption key", err)
+	}
+	return sqlite.NewEncrypted(cfg.Connection, sqlite.EncryptionParams{
+		Key:            key,
+		KDFIter:        cfg.EncryptedSqlite.KDFIter,
+		CipherPageSize: cfg.EncryptedSqlite.CipherPageSize,
+	})
+}
+
+// setConnLimits applies MaxOpenConns/MaxIdleConns to backends that support
+// connection pooling (currently sqlite and encrypted-sqlite); other
+// backends simply ignore these settings.
+func setConnLimits(db trustdb.TrustDB, cfg *Config) {
+	limitSetter, ok := db.(interface {
+		SetMaxOpenConns(int)
+		SetMaxIdleConns(int)
+	})
+	if !ok {
+		return
+	}
+	if n, err := strconv.Atoi(cfg.MaxOpenConns); err == nil {
+		limitSetter.SetMaxOpenConns(n)
+	}
+	if n, err := strconv.Atoi(cfg.MaxIdleConns); err == nil {
+		limitSetter.SetMaxIdleConns(n)
+	}
+}
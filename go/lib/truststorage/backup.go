@@ -0,0 +1,277 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// snapshotMagic and snapshotVersion identify the binary format written by
+// Backup and read by Restore: a versioned header followed, for each table,
+// by its length-prefixed name, its record count and its length-prefixed
+// records. The format
+// only depends on the Tables/DumpTable/LoadTable trio every backend
+// implements, and every backend dumps/loads its rows as a
+// trustdb.KVRecord using the same key shape, so it is backend-agnostic: a
+// snapshot taken from sqlite can be restored into etcd or mongodb and
+// vice versa.
+const (
+	snapshotMagic   = "TDBK"
+	snapshotVersion = 1
+
+	// maxSnapshotFieldLen bounds a single length-prefixed field (a table
+	// name or a record) read from a snapshot. Restore/Migrate read this
+	// data from a file or a remote peer, so it must not be trusted to be
+	// well-formed; without a bound, a corrupted or malicious length prefix
+	// would force a multi-gigabyte allocation before the short read that
+	// follows ever fails.
+	maxSnapshotFieldLen = 64 << 20 // 64 MiB
+	// maxSnapshotCount bounds the table count in the header and the
+	// record count of any single table, for the same reason.
+	maxSnapshotCount = 10_000_000
+	// maxSnapshotPrealloc bounds how many record slots readTable ever
+	// preallocates up front from an attacker-controlled count; any
+	// record count above this still grows the slice incrementally via
+	// append instead of preallocating, since maxSnapshotCount alone still
+	// allows a ~240MB slice-header allocation (10,000,000 * 24 bytes)
+	// from a handful of bytes in the input.
+	maxSnapshotPrealloc = 1024
+)
+
+// snapshotter is implemented by every truststorage backend (sqlite, etcd,
+// mongodb).
+type snapshotter interface {
+	Tables(ctx context.Context) ([]string, error)
+	DumpTable(ctx context.Context, table string) ([][]byte, error)
+	LoadTable(ctx context.Context, table string, records [][]byte) error
+}
+
+// BackupTo writes a portable snapshot of the trust database described by
+// cfg to w. The snapshot can be restored into the same or a different
+// backend with Restore. It is named BackupTo, rather than Backup, because
+// Config already has a Backup field holding the backup-schedule settings.
+func (cfg *Config) BackupTo(ctx context.Context, w io.Writer) error {
+	db, err := cfg.New()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return backup(ctx, db, w)
+}
+
+// Restore replaces the content of the trust database described by cfg
+// with the snapshot read from r, as produced by a prior call to BackupTo.
+func (cfg *Config) Restore(ctx context.Context, r io.Reader) error {
+	db, err := cfg.New()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return restore(ctx, db, r)
+}
+
+// Migrate copies the content of the trust database described by cfg into
+// the trust database described by dst, without an intermediate file. This
+// lets operators move a control service from, e.g., sqlite to a networked
+// backend without losing TRC/cert/key history.
+func (cfg *Config) Migrate(ctx context.Context, dst Config) error {
+	srcDB, err := cfg.New()
+	if err != nil {
+		return serrors.WrapStr("opening source trust db", err)
+	}
+	defer srcDB.Close()
+	dstDB, err := dst.New()
+	if err != nil {
+		return serrors.WrapStr("opening destination trust db", err)
+	}
+	defer dstDB.Close()
+
+	var buf bytes.Buffer
+	if err := backup(ctx, srcDB, &buf); err != nil {
+		return serrors.WrapStr("backing up source trust db", err)
+	}
+	if err := restore(ctx, dstDB, &buf); err != nil {
+		return serrors.WrapStr("restoring destination trust db", err)
+	}
+	return nil
+}
+
+func backup(ctx context.Context, db trustdb.TrustDB, w io.Writer) error {
+	sdb, ok := db.(snapshotter)
+	if !ok {
+		return serrors.New("backend does not support backup/restore")
+	}
+	tables, err := sdb.Tables(ctx)
+	if err != nil {
+		return serrors.WrapStr("listing tables", err)
+	}
+	if err := writeHeader(w, len(tables)); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		records, err := sdb.DumpTable(ctx, table)
+		if err != nil {
+			return serrors.WrapStr("dumping table", err, "table", table)
+		}
+		if err := writeTable(w, table, records); err != nil {
+			return serrors.WrapStr("writing table", err, "table", table)
+		}
+	}
+	return nil
+}
+
+// restore replaces the content of db table by table. The sqlite backend
+// loads each table inside its own transaction, so a failure partway
+// through leaves that table as it was; the etcd and mongodb backends
+// clear a table and then write its records one by one, so a failure there
+// can leave the table partially loaded. The restore as a whole is not
+// atomic across tables on any backend.
+func restore(ctx context.Context, db trustdb.TrustDB, r io.Reader) error {
+	sdb, ok := db.(snapshotter)
+	if !ok {
+		return serrors.New("backend does not support backup/restore")
+	}
+	tableCount, err := readHeader(r)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < tableCount; i++ {
+		table, records, err := readTable(r)
+		if err != nil {
+			return serrors.WrapStr("reading table", err)
+		}
+		if err := sdb.LoadTable(ctx, table, records); err != nil {
+			return serrors.WrapStr("loading table", err, "table", table)
+		}
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, tableCount int) error {
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return serrors.WrapStr("writing magic", err)
+	}
+	header := [2]uint32{snapshotVersion, uint32(tableCount)}
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return serrors.WrapStr("writing header", err)
+	}
+	return nil
+}
+
+func readHeader(r io.Reader) (int, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return 0, serrors.WrapStr("reading magic", err)
+	}
+	if string(magic) != snapshotMagic {
+		return 0, serrors.New("bad snapshot magic", "magic", string(magic))
+	}
+	var header [2]uint32
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, serrors.WrapStr("reading header", err)
+	}
+	version, tableCount := header[0], header[1]
+	if version != snapshotVersion {
+		return 0, serrors.New("unsupported snapshot version", "version", version)
+	}
+	if tableCount > maxSnapshotCount {
+		return 0, serrors.New("snapshot table count exceeds limit",
+			"count", tableCount, "limit", maxSnapshotCount)
+	}
+	return int(tableCount), nil
+}
+
+func writeTable(w io.Writer, table string, records [][]byte) error {
+	if err := writeLenPrefixed(w, []byte(table)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(records))); err != nil {
+		return serrors.WrapStr("writing record count", err)
+	}
+	for _, record := range records {
+		if err := writeLenPrefixed(w, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTable(r io.Reader) (string, [][]byte, error) {
+	name, err := readLenPrefixed(r)
+	if err != nil {
+		return "", nil, err
+	}
+	var recordCount uint32
+	if err := binary.Read(r, binary.BigEndian, &recordCount); err != nil {
+		return "", nil, serrors.WrapStr("reading record count", err)
+	}
+	if recordCount > maxSnapshotCount {
+		return "", nil, serrors.New("snapshot record count exceeds limit",
+			"count", recordCount, "limit", maxSnapshotCount)
+	}
+	// records is grown incrementally, rather than preallocated to
+	// recordCount, so that a bogus (but within-limit) count from a
+	// corrupted or malicious snapshot cannot force a large slice-header
+	// allocation (recordCount * 24 bytes) before any of the records it
+	// claims to hold are actually read off the wire.
+	records := make([][]byte, 0, minInt(int(recordCount), maxSnapshotPrealloc))
+	for i := uint32(0); i < recordCount; i++ {
+		record, err := readLenPrefixed(r)
+		if err != nil {
+			return "", nil, err
+		}
+		records = append(records, record)
+	}
+	return string(name), records, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func writeLenPrefixed(w io.Writer, b []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(b))); err != nil {
+		return serrors.WrapStr("writing length", err)
+	}
+	if _, err := w.Write(b); err != nil {
+		return serrors.WrapStr("writing bytes", err)
+	}
+	return nil
+}
+
+func readLenPrefixed(r io.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, serrors.WrapStr("reading length", err)
+	}
+	if n > maxSnapshotFieldLen {
+		return nil, serrors.New("snapshot field length exceeds limit",
+			"length", n, "limit", maxSnapshotFieldLen)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, serrors.WrapStr("reading bytes", err)
+	}
+	return b, nil
+}
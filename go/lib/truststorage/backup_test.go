@@ -0,0 +1,124 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scionproto/scion/go/lib/truststorage"
+)
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "src.db"),
+	}
+	db, err := src.New()
+	require.NoError(t, err)
+	_, err = db.InsertTRC(ctx, "1", 1, []byte("trc-1-1"))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	var buf bytes.Buffer
+	require.NoError(t, src.BackupTo(ctx, &buf))
+
+	dst := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "dst.db"),
+	}
+	require.NoError(t, dst.Restore(ctx, bytes.NewReader(buf.Bytes())))
+
+	restored, err := dst.New()
+	require.NoError(t, err)
+	defer restored.Close()
+	raw, err := restored.GetTRC(ctx, "1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "trc-1-1", string(raw))
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "src.db"),
+	}
+	db, err := src.New()
+	require.NoError(t, err)
+	_, err = db.InsertChain(ctx, "1-ff00:0:110", []byte("chain-110"))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	dst := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "dst.db"),
+	}
+	require.NoError(t, src.Migrate(ctx, dst))
+
+	migrated, err := dst.New()
+	require.NoError(t, err)
+	defer migrated.Close()
+	raw, err := migrated.GetChain(ctx, "1-ff00:0:110")
+	require.NoError(t, err)
+	assert.Equal(t, "chain-110", string(raw))
+}
+
+// TestRestoreRejectsOversizedCounts guards against a corrupted or
+// malicious snapshot forcing a huge allocation before the short read that
+// follows it ever fails: both a bogus table count in the header and a
+// bogus field length inside a table must be rejected up front.
+func TestRestoreRejectsOversizedCounts(t *testing.T) {
+	dst := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "dst.db"),
+	}
+	ctx := context.Background()
+
+	t.Run("oversized table count", func(t *testing.T) {
+		var buf bytes.Buffer
+		buf.WriteString("TDBK")
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, [2]uint32{1, 0xffffffff}))
+		assert.Error(t, dst.Restore(ctx, &buf))
+	})
+
+	t.Run("oversized field length", func(t *testing.T) {
+		var buf bytes.Buffer
+		buf.WriteString("TDBK")
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, [2]uint32{1, 1}))
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, uint32(0xffffffff)))
+		assert.Error(t, dst.Restore(ctx, &buf))
+	})
+
+	// A record count that is within maxSnapshotCount but still far larger
+	// than the (short) input that actually follows it must fail on the
+	// first truncated read, not after preallocating a huge slice for
+	// records it will never find.
+	t.Run("within-limit count but truncated input", func(t *testing.T) {
+		var buf bytes.Buffer
+		buf.WriteString("TDBK")
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, [2]uint32{1, 1}))
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, uint32(len("trcs"))))
+		buf.WriteString("trcs")
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, uint32(9_999_999)))
+		assert.Error(t, dst.Restore(ctx, &buf))
+	})
+}
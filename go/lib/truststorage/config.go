@@ -0,0 +1,254 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/config"
+	"github.com/scionproto/scion/go/lib/serrors"
+	"github.com/scionproto/scion/go/lib/util"
+)
+
+const (
+	// BackendSqlite indicates an on-disk, unencrypted sqlite trust database.
+	BackendSqlite = "sqlite"
+	// BackendEncryptedSqlite indicates an on-disk sqlite trust database that
+	// is transparently encrypted at rest via a SQLCipher-compatible driver.
+	BackendEncryptedSqlite = "encrypted-sqlite"
+	// BackendEtcd indicates a trust database backed by an etcd cluster,
+	// allowing multiple control-service replicas to share TRC/cert state.
+	BackendEtcd = "etcd"
+	// BackendMongo indicates a trust database backed by a MongoDB instance.
+	BackendMongo = "mongodb"
+
+	// DefaultKDFIter is the default number of KDF iterations used to derive
+	// the page encryption key for the encrypted-sqlite backend.
+	DefaultKDFIter = 64000
+	// DefaultCipherPageSize is the default page size, in bytes, used by the
+	// encrypted-sqlite backend.
+	DefaultCipherPageSize = 4096
+	// DefaultEtcdDialTimeout is the default timeout for the initial
+	// connection to the etcd cluster.
+	DefaultEtcdDialTimeout = 5 * time.Second
+
+	idSample = "1-ff00:0:110"
+)
+
+// Config is the configuration for the trust database backend. Only the
+// nested table that corresponds to Backend is read; the others are ignored.
+type Config struct {
+	// Backend is the type of backend to use. One of "sqlite",
+	// "encrypted-sqlite", "etcd", "mongodb". (default sqlite)
+	Backend string `toml:"backend"`
+	// Connection is the connection string for the trust database. It is
+	// used by the sqlite and encrypted-sqlite backends.
+	Connection string `toml:"connection"`
+	// MaxOpenConns sets the maximum number of open connections to the
+	// database. Empty means the go default is used.
+	MaxOpenConns string `toml:"max_open_conns"`
+	// MaxIdleConns sets the maximum number of idle connections to the
+	// database. Empty means the go default is used.
+	MaxIdleConns string `toml:"max_idle_conns"`
+	// EncryptedSqlite holds the additional settings required by the
+	// encrypted-sqlite backend. It is only read if Backend is set to
+	// BackendEncryptedSqlite.
+	EncryptedSqlite EncryptedSqliteConfig `toml:"encrypted_sqlite"`
+	// Etcd holds the settings required by the etcd backend. It is only
+	// read if Backend is set to BackendEtcd.
+	Etcd EtcdConfig `toml:"etcd"`
+	// Mongo holds the settings required by the mongodb backend. It is only
+	// read if Backend is set to BackendMongo.
+	Mongo MongoConfig `toml:"mongodb"`
+	// Backup holds the settings for periodic backups of the trust
+	// database, taken via Config.BackupTo (see StartBackupScheduler).
+	Backup BackupConfig `toml:"backup"`
+}
+
+// EtcdConfig holds the settings for the etcd backend.
+type EtcdConfig struct {
+	// Endpoints is the list of etcd cluster endpoints to connect to, e.g.
+	// ["https://etcd0:2379", "https://etcd1:2379"].
+	Endpoints []string `toml:"endpoints"`
+	// KeyPrefix is prepended to every key the backend writes, so that
+	// multiple deployments (or ISDs) can share a single etcd cluster.
+	KeyPrefix string `toml:"key_prefix"`
+	// DialTimeout bounds how long to wait for the initial connection to the
+	// cluster. (default 5s)
+	DialTimeout util.DurWrap `toml:"dial_timeout"`
+	// CertFile, KeyFile and CAFile configure mutual TLS towards etcd.
+	// (optional, but recommended for shared clusters)
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	CAFile   string `toml:"ca_file"`
+}
+
+// MongoConfig holds the settings for the mongodb backend.
+type MongoConfig struct {
+	// URI is the mongodb connection URI, e.g. "mongodb://localhost:27017".
+	URI string `toml:"uri"`
+	// Database is the name of the database the trust collections live in.
+	Database string `toml:"database"`
+	// Collection is the name of the collection trust material is stored in.
+	Collection string `toml:"collection"`
+}
+
+// EncryptedSqliteConfig holds the settings for the encrypted-sqlite backend.
+type EncryptedSqliteConfig struct {
+	// KeyFile is the path to a file containing the raw encryption key. The
+	// key itself is never read from the TOML configuration so that it does
+	// not end up in config dumps or process listings.
+	KeyFile string `toml:"key_file"`
+	// KDFIter is the number of KDF iterations used to derive the page
+	// encryption key. (default 64000)
+	KDFIter int `toml:"kdf_iter"`
+	// CipherPageSize is the page size, in bytes, used by the encrypted
+	// database. (default 4096)
+	CipherPageSize int `toml:"cipher_page_size"`
+}
+
+// BackupConfig holds the settings for periodic trust database backups.
+// Snapshots are written with mode 0600 but are never themselves
+// encrypted, even when Backend is "encrypted-sqlite"; secure Path
+// accordingly (see RunScheduledBackup).
+type BackupConfig struct {
+	// Schedule is a cron expression controlling how often a backup is
+	// taken, e.g. "0 */6 * * *" for every six hours. Empty disables
+	// periodic backups. (optional)
+	Schedule string `toml:"schedule"`
+	// Path is the file periodic snapshots are written to. Required if
+	// Schedule is set.
+	Path string `toml:"path"`
+}
+
+var _ config.Config = (*Config)(nil)
+
+// InitDefaults initializes the default values for unset fields.
+func (cfg *Config) InitDefaults() {
+	if cfg.Backend == "" {
+		cfg.Backend = BackendSqlite
+	}
+	if cfg.Backend == BackendEncryptedSqlite {
+		if cfg.EncryptedSqlite.KDFIter == 0 {
+			cfg.EncryptedSqlite.KDFIter = DefaultKDFIter
+		}
+		if cfg.EncryptedSqlite.CipherPageSize == 0 {
+			cfg.EncryptedSqlite.CipherPageSize = DefaultCipherPageSize
+		}
+	}
+	if cfg.Backend == BackendEtcd && cfg.Etcd.DialTimeout.Duration == 0 {
+		cfg.Etcd.DialTimeout.Duration = DefaultEtcdDialTimeout
+	}
+}
+
+// Validate validates the configuration by looking up the backend-specific
+// Validate() method for cfg.Backend in the backend registry.
+func (cfg *Config) Validate() error {
+	b, ok := backends[cfg.Backend]
+	if !ok {
+		return serrors.New("unsupported backend", "backend", cfg.Backend)
+	}
+	if err := b.validate(cfg); err != nil {
+		return err
+	}
+	return cfg.Backup.Validate()
+}
+
+// Validate validates the backup configuration.
+func (cfg *BackupConfig) Validate() error {
+	if cfg.Schedule != "" && cfg.Path == "" {
+		return serrors.New("path must be set when backup.schedule is set")
+	}
+	return nil
+}
+
+// validateSqliteConn validates the connection string shared by the
+// sqlite and encrypted-sqlite backends.
+func (cfg *Config) validateSqliteConn() error {
+	if cfg.Connection == "" {
+		return serrors.New("connection must be set")
+	}
+	return nil
+}
+
+// Validate validates the etcd configuration.
+func (cfg *EtcdConfig) Validate() error {
+	if len(cfg.Endpoints) == 0 {
+		return serrors.New("at least one endpoint must be set for etcd backend")
+	}
+	if cfg.KeyPrefix == "" {
+		return serrors.New("key_prefix must be set for etcd backend")
+	}
+	return nil
+}
+
+// Validate validates the mongodb configuration.
+func (cfg *MongoConfig) Validate() error {
+	if cfg.URI == "" {
+		return serrors.New("uri must be set for mongodb backend")
+	}
+	if cfg.Database == "" {
+		return serrors.New("database must be set for mongodb backend")
+	}
+	if cfg.Collection == "" {
+		return serrors.New("collection must be set for mongodb backend")
+	}
+	return nil
+}
+
+// Validate validates the encrypted-sqlite configuration and ensures the key
+// file exists and is not readable by other users or groups. The connection
+// string itself is validated separately, see validateSqliteConn.
+func (cfg *EncryptedSqliteConfig) Validate() error {
+	if cfg.KeyFile == "" {
+		return serrors.New("key_file must be set for encrypted-sqlite backend")
+	}
+	info, err := os.Stat(cfg.KeyFile)
+	if err != nil {
+		return serrors.WrapStr("checking key_file", err, "file", cfg.KeyFile)
+	}
+	if info.Mode()&0077 != 0 {
+		return serrors.New("key_file permissions too permissive, expected no group or other access",
+			"file", cfg.KeyFile, "mode", info.Mode())
+	}
+	return nil
+}
+
+// LoadKey reads and returns the raw encryption key material referenced by
+// KeyFile. The key is intentionally never sourced from the TOML config
+// itself, only from this file, so that it is not accidentally persisted
+// alongside the rest of the (non-sensitive) configuration.
+func (cfg *EncryptedSqliteConfig) LoadKey() ([]byte, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	key, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, serrors.WrapStr("reading key_file", err, "file", cfg.KeyFile)
+	}
+	return key, nil
+}
+
+// ConfigName returns the name of this config used in the toml file.
+func (cfg *Config) ConfigName() string {
+	return "trustdb"
+}
+
+// Sample writes a config sample to the writer.
+func (cfg *Config) Sample(dst io.Writer, path config.Path, _ config.CtxMap) {
+	config.WriteSample(dst, path, config.CtxMap{config.ID: idSample}, trustDbSample)
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scionproto/scion/go/lib/truststorage"
+)
+
+func TestInitDefaults(t *testing.T) {
+	t.Run("empty backend defaults to sqlite", func(t *testing.T) {
+		cfg := truststorage.Config{}
+		cfg.InitDefaults()
+		assert.Equal(t, truststorage.BackendSqlite, cfg.Backend)
+	})
+	t.Run("encrypted-sqlite fills in kdf/page size defaults", func(t *testing.T) {
+		cfg := truststorage.Config{Backend: truststorage.BackendEncryptedSqlite}
+		cfg.InitDefaults()
+		assert.Equal(t, truststorage.DefaultKDFIter, cfg.EncryptedSqlite.KDFIter)
+		assert.Equal(t, truststorage.DefaultCipherPageSize, cfg.EncryptedSqlite.CipherPageSize)
+	})
+	t.Run("encrypted-sqlite keeps explicit values", func(t *testing.T) {
+		cfg := truststorage.Config{
+			Backend: truststorage.BackendEncryptedSqlite,
+			EncryptedSqlite: truststorage.EncryptedSqliteConfig{
+				KDFIter:        1,
+				CipherPageSize: 1024,
+			},
+		}
+		cfg.InitDefaults()
+		assert.Equal(t, 1, cfg.EncryptedSqlite.KDFIter)
+		assert.Equal(t, 1024, cfg.EncryptedSqlite.CipherPageSize)
+	})
+	t.Run("etcd fills in dial timeout default", func(t *testing.T) {
+		cfg := truststorage.Config{Backend: truststorage.BackendEtcd}
+		cfg.InitDefaults()
+		assert.Equal(t, truststorage.DefaultEtcdDialTimeout, cfg.Etcd.DialTimeout.Duration)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "trust.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key-material"), 0600))
+
+	tests := map[string]struct {
+		cfg     truststorage.Config
+		wantErr bool
+	}{
+		"unsupported backend": {
+			cfg:     truststorage.Config{Backend: "bogus"},
+			wantErr: true,
+		},
+		"sqlite without connection": {
+			cfg:     truststorage.Config{Backend: truststorage.BackendSqlite},
+			wantErr: true,
+		},
+		"sqlite with connection": {
+			cfg: truststorage.Config{
+				Backend:    truststorage.BackendSqlite,
+				Connection: "/tmp/test.db",
+			},
+			wantErr: false,
+		},
+		"encrypted-sqlite without key_file": {
+			cfg: truststorage.Config{
+				Backend:    truststorage.BackendEncryptedSqlite,
+				Connection: "/tmp/test.db",
+			},
+			wantErr: true,
+		},
+		"encrypted-sqlite with key_file": {
+			cfg: truststorage.Config{
+				Backend:         truststorage.BackendEncryptedSqlite,
+				Connection:      "/tmp/test.db",
+				EncryptedSqlite: truststorage.EncryptedSqliteConfig{KeyFile: keyFile},
+			},
+			wantErr: false,
+		},
+		"etcd without endpoints": {
+			cfg:     truststorage.Config{Backend: truststorage.BackendEtcd},
+			wantErr: true,
+		},
+		"etcd with endpoints and key_prefix": {
+			cfg: truststorage.Config{
+				Backend: truststorage.BackendEtcd,
+				Etcd: truststorage.EtcdConfig{
+					Endpoints: []string{"https://127.0.0.1:2379"},
+					KeyPrefix: "/scion/trustdb/",
+				},
+			},
+			wantErr: false,
+		},
+		"mongodb missing fields": {
+			cfg:     truststorage.Config{Backend: truststorage.BackendMongo},
+			wantErr: true,
+		},
+		"mongodb with all fields": {
+			cfg: truststorage.Config{
+				Backend: truststorage.BackendMongo,
+				Mongo: truststorage.MongoConfig{
+					URI:        "mongodb://127.0.0.1:27017",
+					Database:   "scion",
+					Collection: "trustdb",
+				},
+			},
+			wantErr: false,
+		},
+		"backup schedule without path": {
+			cfg: truststorage.Config{
+				Backend:    truststorage.BackendSqlite,
+				Connection: "/tmp/test.db",
+				Backup:     truststorage.BackupConfig{Schedule: "0 */6 * * *"},
+			},
+			wantErr: true,
+		},
+		"backup schedule with path": {
+			cfg: truststorage.Config{
+				Backend:    truststorage.BackendSqlite,
+				Connection: "/tmp/test.db",
+				Backup:     truststorage.BackupConfig{Schedule: "0 */6 * * *", Path: "/tmp/test.bak"},
+			},
+			wantErr: false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.cfg.Validate()
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
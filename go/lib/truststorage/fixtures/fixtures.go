@@ -0,0 +1,175 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fixtures loads declarative trust-database fixtures -- initial
+// TRCs, certificate chains and key entries described as JSON or YAML
+// files -- and seeds them into any go/lib/truststorage backend via Seed,
+// replacing the ad-hoc seeding previously scattered across trust-related
+// tests. VerifySnapshot provides the other half, asserting that a
+// backend's final content matches a recorded expectation.
+package fixtures
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// TRCEntry describes a single TRC to seed.
+type TRCEntry struct {
+	ISD     string `json:"isd" yaml:"isd"`
+	Version int    `json:"version" yaml:"version"`
+	// Raw is the raw TRC content, base64-encoded in the fixture file. Set
+	// exactly one of Raw and RawText.
+	Raw []byte `json:"raw,omitempty" yaml:"raw,omitempty"`
+	// RawText is a plain-text stand-in for Raw, expanded for macros before
+	// being stored verbatim. Most fixtures use this instead of Raw, since
+	// the trust database treats the content as opaque bytes.
+	RawText string `json:"raw_text,omitempty" yaml:"raw_text,omitempty"`
+}
+
+// ChainEntry describes a single certificate chain to seed.
+type ChainEntry struct {
+	IA      string `json:"ia" yaml:"ia"`
+	Raw     []byte `json:"raw,omitempty" yaml:"raw,omitempty"`
+	RawText string `json:"raw_text,omitempty" yaml:"raw_text,omitempty"`
+}
+
+// KeyEntry describes a single key to seed.
+type KeyEntry struct {
+	IA      string `json:"ia" yaml:"ia"`
+	Usage   string `json:"usage" yaml:"usage"`
+	Raw     []byte `json:"raw,omitempty" yaml:"raw,omitempty"`
+	RawText string `json:"raw_text,omitempty" yaml:"raw_text,omitempty"`
+}
+
+func (e TRCEntry) raw() []byte {
+	if e.RawText != "" {
+		return []byte(e.RawText)
+	}
+	return e.Raw
+}
+
+func (e ChainEntry) raw() []byte {
+	if e.RawText != "" {
+		return []byte(e.RawText)
+	}
+	return e.Raw
+}
+
+func (e KeyEntry) raw() []byte {
+	if e.RawText != "" {
+		return []byte(e.RawText)
+	}
+	return e.Raw
+}
+
+// Set is the content of a fixture file. A fixture directory may contain
+// several files; LoadDir concatenates the slices of every file it reads.
+type Set struct {
+	TRCs   []TRCEntry   `json:"trcs,omitempty" yaml:"trcs,omitempty"`
+	Chains []ChainEntry `json:"chains,omitempty" yaml:"chains,omitempty"`
+	Keys   []KeyEntry   `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// fixtureGlob pairs a file glob pattern with the unmarshal func used to
+// decode files it matches.
+type fixtureGlob struct {
+	pattern   string
+	unmarshal func([]byte, *Set) error
+}
+
+// fixtureGlobs lists the file patterns LoadDir considers fixture files,
+// and how each is decoded. It is a slice, rather than a map, so that
+// LoadDir's across-pattern read order is fixed instead of randomized
+// per run.
+var fixtureGlobs = []fixtureGlob{
+	{"*.json", func(raw []byte, s *Set) error { return json.Unmarshal(raw, s) }},
+	{"*.yaml", func(raw []byte, s *Set) error { return yaml.Unmarshal(raw, s) }},
+	{"*.yml", func(raw []byte, s *Set) error { return yaml.Unmarshal(raw, s) }},
+}
+
+// LoadDir reads every JSON or YAML fixture file directly inside dir,
+// expands macros (see expandMacros) against now, and merges the result
+// into a single Set. Files are read in lexical order within, and then
+// across, the glob patterns in fixtureGlobs, but the merged Set makes no
+// guarantee about entry order beyond that.
+func LoadDir(dir string, now time.Time) (Set, error) {
+	var merged Set
+	for _, fg := range fixtureGlobs {
+		matches, err := filepath.Glob(filepath.Join(dir, fg.pattern))
+		if err != nil {
+			return Set{}, serrors.WrapStr("listing fixture files", err, "dir", dir, "glob", fg.pattern)
+		}
+		for _, path := range matches {
+			raw, err := ioutil.ReadFile(path)
+			if err != nil {
+				return Set{}, serrors.WrapStr("reading fixture file", err, "file", path)
+			}
+			var s Set
+			if err := fg.unmarshal(raw, &s); err != nil {
+				return Set{}, serrors.WrapStr("parsing fixture file", err, "file", path)
+			}
+			if err := s.expandMacros(now); err != nil {
+				return Set{}, serrors.WrapStr("expanding macros", err, "file", path)
+			}
+			merged.TRCs = append(merged.TRCs, s.TRCs...)
+			merged.Chains = append(merged.Chains, s.Chains...)
+			merged.Keys = append(merged.Keys, s.Keys...)
+		}
+	}
+	return merged, nil
+}
+
+func (s *Set) expandMacros(now time.Time) error {
+	for i := range s.TRCs {
+		e := &s.TRCs[i]
+		var err error
+		if e.ISD, err = expandMacros(e.ISD, now); err != nil {
+			return err
+		}
+		if e.RawText, err = expandMacros(e.RawText, now); err != nil {
+			return err
+		}
+	}
+	for i := range s.Chains {
+		e := &s.Chains[i]
+		var err error
+		if e.IA, err = expandMacros(e.IA, now); err != nil {
+			return err
+		}
+		if e.RawText, err = expandMacros(e.RawText, now); err != nil {
+			return err
+		}
+	}
+	for i := range s.Keys {
+		e := &s.Keys[i]
+		var err error
+		if e.IA, err = expandMacros(e.IA, now); err != nil {
+			return err
+		}
+		if e.Usage, err = expandMacros(e.Usage, now); err != nil {
+			return err
+		}
+		if e.RawText, err = expandMacros(e.RawText, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
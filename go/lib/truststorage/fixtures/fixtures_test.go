@@ -0,0 +1,129 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixtures_test
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb/sqlite"
+	"github.com/scionproto/scion/go/lib/truststorage/fixtures"
+)
+
+var fixedNow = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func writeFixture(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+}
+
+func TestLoadDirExpandsMacros(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "trcs.json", `{
+		"trcs": [
+			{"isd": "<isd:1-ff00:0:110>", "version": 1, "raw_text": "valid-from=<now>"}
+		]
+	}`)
+
+	set, err := fixtures.LoadDir(dir, fixedNow)
+	require.NoError(t, err)
+	require.Len(t, set.TRCs, 1)
+	assert.Equal(t, "1", set.TRCs[0].ISD)
+	assert.Equal(t, "valid-from=2020-01-02T03:04:05Z", set.TRCs[0].RawText)
+}
+
+func TestLoadDirYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "chains.yaml", `
+chains:
+  - ia: "<isd:1-ff00:0:110>-ff00:0:110"
+    raw_text: "valid-from=<now>"
+`)
+
+	set, err := fixtures.LoadDir(dir, fixedNow)
+	require.NoError(t, err)
+	require.Len(t, set.Chains, 1)
+	assert.Equal(t, "1-ff00:0:110", set.Chains[0].IA)
+	assert.Equal(t, "valid-from=2020-01-02T03:04:05Z", set.Chains[0].RawText)
+}
+
+func TestLoadDirDeterministicOrderAcrossPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "a.json", `{"trcs": [{"isd": "1", "version": 1, "raw_text": "from-json"}]}`)
+	writeFixture(t, dir, "b.yaml", "trcs:\n  - isd: \"1\"\n    version: 1\n    raw_text: from-yaml\n")
+
+	// LoadDir itself only concatenates entries; it does not dedupe. What
+	// must be deterministic, run after run, is the order the files are
+	// concatenated in (lexical within a pattern, json before yaml/yml
+	// across patterns), since callers such as Seed rely on
+	// last-one-wins (INSERT OR REPLACE) semantics.
+	for i := 0; i < 5; i++ {
+		set, err := fixtures.LoadDir(dir, fixedNow)
+		require.NoError(t, err)
+		require.Len(t, set.TRCs, 2)
+		assert.Equal(t, "from-json", set.TRCs[0].RawText)
+		assert.Equal(t, "from-yaml", set.TRCs[1].RawText)
+	}
+}
+
+func TestLoadDirUnknownMacro(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "trcs.json", `{"trcs": [{"isd": "<bogus>", "version": 1}]}`)
+
+	_, err := fixtures.LoadDir(dir, fixedNow)
+	assert.Error(t, err)
+}
+
+func TestSeedAndVerifySnapshot(t *testing.T) {
+	fixtureDir := t.TempDir()
+	writeFixture(t, fixtureDir, "seed.json", `{
+		"trcs":   [{"isd": "1", "version": 1, "raw_text": "trc-1-1"}],
+		"chains": [{"ia": "1-ff00:0:110", "raw_text": "chain-110"}],
+		"keys":   [{"ia": "1-ff00:0:110", "usage": "as-signing", "raw_text": "key-110"}]
+	}`)
+
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "trust.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, fixtures.SeedAt(ctx, db, fixtureDir, fixedNow))
+
+	raw, err := db.GetTRC(ctx, "1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "trc-1-1", string(raw))
+
+	expectedDir := t.TempDir()
+	writeFixture(t, expectedDir, "trcs.json",
+		`[{"key":"1/1","value":"`+b64("trc-1-1")+`"}]`)
+	writeFixture(t, expectedDir, "chains.json",
+		`[{"key":"1-ff00:0:110","value":"`+b64("chain-110")+`"}]`)
+	writeFixture(t, expectedDir, "keys.json",
+		`[{"key":"1-ff00:0:110/as-signing","value":"`+b64("key-110")+`"}]`)
+
+	assert.NoError(t, fixtures.VerifySnapshot(ctx, db, expectedDir))
+	assert.NoError(t, fixtures.VerifySnapshot(ctx, db, expectedDir, "trcs"))
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixtures
+
+import (
+	"strings"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// expandMacros replaces every `<...>` macro expression in s and returns the
+// result. Two macros are recognized:
+//
+//   <now>, <now+1h>, <now-30m>  expands to now, optionally offset by a
+//     signed duration, formatted as RFC3339. Fixtures use this for TRC and
+//     certificate validity periods so they straddle the load time instead
+//     of going stale.
+//   <isd:1-ff00:0:110>          expands to the ISD component of the given
+//     IA, so an isd field can be derived from an ia field in the same
+//     entry instead of being kept in sync by hand.
+//
+// An unrecognized macro is an error, to catch typos early.
+func expandMacros(s string, now time.Time) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(s, '<')
+		if start < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		end := strings.IndexByte(s[start:], '>')
+		if end < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		end += start
+		sb.WriteString(s[:start])
+		expanded, err := expandMacro(s[start+1:end], now)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(expanded)
+		s = s[end+1:]
+	}
+}
+
+func expandMacro(macro string, now time.Time) (string, error) {
+	switch {
+	case macro == "now":
+		return now.Format(time.RFC3339), nil
+	case strings.HasPrefix(macro, "now+"), strings.HasPrefix(macro, "now-"):
+		d, err := time.ParseDuration(macro[3:])
+		if err != nil {
+			return "", serrors.WrapStr("parsing <now> offset", err, "macro", macro)
+		}
+		return now.Add(d).Format(time.RFC3339), nil
+	case strings.HasPrefix(macro, "isd:"):
+		ia := macro[len("isd:"):]
+		idx := strings.IndexByte(ia, '-')
+		if idx < 0 {
+			return "", serrors.New("malformed ia in <isd:...> macro", "macro", macro)
+		}
+		return ia[:idx], nil
+	default:
+		return "", serrors.New("unknown macro", "macro", macro)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixtures
+
+import (
+	"context"
+	"time"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// Seed loads the fixture files in fixtureDir and inserts every TRC, chain
+// and key entry they describe into db, in that order. Macros are expanded
+// against the current time; use SeedAt for deterministic tests.
+func Seed(ctx context.Context, db trustdb.TrustDB, fixtureDir string) error {
+	return SeedAt(ctx, db, fixtureDir, time.Now())
+}
+
+// SeedAt behaves like Seed, but expands <now>-style macros against now
+// instead of the wall clock.
+func SeedAt(ctx context.Context, db trustdb.TrustDB, fixtureDir string, now time.Time) error {
+	set, err := LoadDir(fixtureDir, now)
+	if err != nil {
+		return err
+	}
+	for _, e := range set.TRCs {
+		if _, err := db.InsertTRC(ctx, e.ISD, e.Version, e.raw()); err != nil {
+			return serrors.WrapStr("seeding trc", err, "isd", e.ISD, "version", e.Version)
+		}
+	}
+	for _, e := range set.Chains {
+		if _, err := db.InsertChain(ctx, e.IA, e.raw()); err != nil {
+			return serrors.WrapStr("seeding chain", err, "ia", e.IA)
+		}
+	}
+	for _, e := range set.Keys {
+		if err := db.InsertKey(ctx, e.IA, e.Usage, e.raw()); err != nil {
+			return serrors.WrapStr("seeding key", err, "ia", e.IA, "usage", e.Usage)
+		}
+	}
+	return nil
+}
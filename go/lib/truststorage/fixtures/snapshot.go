@@ -0,0 +1,126 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/scionproto/scion/go/lib/infra/modules/trust/trustdb"
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// snapshotDB is implemented by every truststorage backend (sqlite, etcd,
+// mongodb); it is the same Tables/DumpTable pair go/lib/truststorage's
+// Backup/Restore/Migrate build on.
+type snapshotDB interface {
+	Tables(ctx context.Context) ([]string, error)
+	DumpTable(ctx context.Context, table string) ([][]byte, error)
+}
+
+// VerifySnapshot asserts that db's current content matches the expected
+// state recorded under expectedDir, which holds one "<table>.json" file
+// per table -- each a JSON array of the records DumpTable returns for that
+// table. If tables is non-empty, only those tables are compared; otherwise
+// every table reported by db is checked. Records are compared as sets, so
+// dump order does not matter.
+func VerifySnapshot(ctx context.Context, db trustdb.TrustDB, expectedDir string, tables ...string) error {
+	sdb, ok := db.(snapshotDB)
+	if !ok {
+		return serrors.New("backend does not support snapshotting")
+	}
+	if len(tables) == 0 {
+		all, err := sdb.Tables(ctx)
+		if err != nil {
+			return serrors.WrapStr("listing tables", err)
+		}
+		tables = all
+	}
+	for _, table := range tables {
+		got, err := sdb.DumpTable(ctx, table)
+		if err != nil {
+			return serrors.WrapStr("dumping table", err, "table", table)
+		}
+		want, err := readExpectedTable(expectedDir, table)
+		if err != nil {
+			return err
+		}
+		if err := diffRecords(table, want, got); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readExpectedTable(dir, table string) ([][]byte, error) {
+	path := filepath.Join(dir, table+".json")
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, serrors.WrapStr("reading expected snapshot", err, "file", path)
+	}
+	var records []json.RawMessage
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, serrors.WrapStr("parsing expected snapshot", err, "file", path)
+	}
+	out := make([][]byte, len(records))
+	for i, r := range records {
+		out[i] = []byte(r)
+	}
+	return out, nil
+}
+
+// diffRecords compares want and got as sets of canonicalized JSON records,
+// so that differences in dump order or key order do not cause a mismatch.
+func diffRecords(table string, want, got [][]byte) error {
+	wantNorm, err := canonicalize(table, want)
+	if err != nil {
+		return err
+	}
+	gotNorm, err := canonicalize(table, got)
+	if err != nil {
+		return err
+	}
+	if len(wantNorm) != len(gotNorm) {
+		return serrors.New("snapshot mismatch: record count",
+			"table", table, "want", len(wantNorm), "got", len(gotNorm))
+	}
+	for i := range wantNorm {
+		if wantNorm[i] != gotNorm[i] {
+			return serrors.New("snapshot mismatch",
+				"table", table, "want", wantNorm[i], "got", gotNorm[i])
+		}
+	}
+	return nil
+}
+
+func canonicalize(table string, records [][]byte) ([]string, error) {
+	out := make([]string, len(records))
+	for i, r := range records {
+		var v interface{}
+		if err := json.Unmarshal(r, &v); err != nil {
+			return nil, serrors.WrapStr("normalizing record", err, "table", table)
+		}
+		canon, err := json.Marshal(v)
+		if err != nil {
+			return nil, serrors.WrapStr("normalizing record", err, "table", table)
+		}
+		out[i] = string(canon)
+	}
+	sort.Strings(out)
+	return out, nil
+}
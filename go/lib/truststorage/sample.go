@@ -15,10 +15,12 @@
 package truststorage
 
 const trustDbSample = `
-# The type of trustdb backend. (default sqlite)
+# The type of trustdb backend. One of "sqlite", "encrypted-sqlite", "etcd",
+# "mongodb". (default sqlite)
 backend = "sqlite"
 
-# Connection for the trust database. (required)
+# Connection for the trust database. Used by the sqlite and
+# encrypted-sqlite backends. (required for sqlite, encrypted-sqlite)
 connection = "/var/lib/scion/spki/%s.trust.db"
 
 # The maximum number of open connections to the database. In case of the
@@ -28,4 +30,67 @@ max_open_conns = ""
 # The maximum number of idle connections to the database. In case of the
 # empty string, the limit is not set and uses the go default. (default "")
 max_idle_conns = ""
+
+# Settings for the encrypted-sqlite backend. Only read if backend is set to
+# "encrypted-sqlite".
+[encrypted_sqlite]
+
+# Path to a file holding the raw encryption key. The key material is always
+# read from this file, never from this configuration file, so that it is
+# not leaked via config dumps. The file must not be readable by group or
+# other. (required for encrypted-sqlite)
+key_file = "/var/lib/scion/spki/%s.trust.key"
+
+# The number of KDF iterations used to derive the page encryption key.
+# (default 64000)
+kdf_iter = 64000
+
+# The page size, in bytes, used by the encrypted database. (default 4096)
+cipher_page_size = 4096
+
+# Settings for the etcd backend. Only read if backend is set to "etcd".
+# Several control-service replicas can point at the same cluster to share
+# TRC/cert state without a shared filesystem.
+[etcd]
+
+# The list of etcd cluster endpoints to connect to. (required for etcd)
+endpoints = ["https://127.0.0.1:2379"]
+
+# Prefix prepended to every key this backend writes, so that multiple
+# deployments can share a single etcd cluster. (required for etcd)
+key_prefix = "/scion/trustdb/"
+
+# Timeout for the initial connection to the etcd cluster. (default 5s)
+dial_timeout = "5s"
+
+# Client certificate, key and CA used for mutual TLS towards etcd.
+# (optional)
+cert_file = ""
+key_file = ""
+ca_file = ""
+
+# Settings for the mongodb backend. Only read if backend is set to
+# "mongodb".
+[mongodb]
+
+# The mongodb connection URI. (required for mongodb)
+uri = "mongodb://127.0.0.1:27017"
+
+# The database the trust collections live in. (required for mongodb)
+database = "scion"
+
+# The collection trust material is stored in. (required for mongodb)
+collection = "trustdb"
+
+# Settings for periodic trust database backups, see StartBackupScheduler.
+# Snapshots are written with mode 0600 but are never themselves encrypted,
+# even if backend is "encrypted-sqlite"; secure path accordingly.
+[backup]
+
+# Cron expression controlling how often a backup is taken. Empty disables
+# periodic backups. (default "")
+schedule = ""
+
+# The file periodic snapshots are written to. (required if schedule is set)
+path = ""
 `
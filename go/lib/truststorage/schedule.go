@@ -0,0 +1,98 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage
+
+import (
+	"context"
+	"os"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/scionproto/scion/go/lib/serrors"
+)
+
+// RunScheduledBackup performs a single backup tick: it writes a BackupTo
+// snapshot of cfg to cfg.Backup.Path, overwriting any existing file. It is
+// called on every cron tick by StartBackupScheduler, and is exported so
+// callers (and tests) can trigger a tick directly without waiting on the
+// schedule.
+//
+// The snapshot itself is never encrypted, even if cfg.Backend is
+// "encrypted-sqlite": BackupTo writes the plaintext TRC/chain/key content
+// read back out of the backend. The backup file is created with mode
+// 0600 so it is at least not world- or group-readable on disk, but an
+// encrypted-sqlite deployment that also sets backup.schedule still needs
+// the backup path itself secured (e.g. an encrypted filesystem or volume)
+// to keep the same at-rest confidentiality as the source database.
+func (cfg *Config) RunScheduledBackup(ctx context.Context) error {
+	if cfg.Backup.Path == "" {
+		return serrors.New("backup.path must be set to run a scheduled backup")
+	}
+	f, err := os.OpenFile(cfg.Backup.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return serrors.WrapStr("creating backup file", err, "path", cfg.Backup.Path)
+	}
+	defer f.Close()
+	return cfg.BackupTo(ctx, f)
+}
+
+// BackupScheduler periodically calls Config.RunScheduledBackup according
+// to Config.Backup.Schedule, started by StartBackupScheduler.
+type BackupScheduler struct {
+	cron *cron.Cron
+	errs chan error
+}
+
+// StartBackupScheduler parses cfg.Backup.Schedule as a standard five-field
+// cron expression or a descriptor such as "@every 1h" (intervals below
+// one second are rounded up to one second), and starts a background
+// scheduler that calls
+// cfg.RunScheduledBackup on every tick, writing periodic snapshots to
+// cfg.Backup.Path. It returns (nil, nil) if cfg.Backup.Schedule is empty,
+// since periodic backups are optional. Callers must Stop the returned
+// scheduler once it is no longer needed.
+func StartBackupScheduler(cfg *Config) (*BackupScheduler, error) {
+	if cfg.Backup.Schedule == "" {
+		return nil, nil
+	}
+	s := &BackupScheduler{cron: cron.New(), errs: make(chan error, 1)}
+	_, err := s.cron.AddFunc(cfg.Backup.Schedule, func() {
+		if err := cfg.RunScheduledBackup(context.Background()); err != nil {
+			select {
+			case s.errs <- err:
+			default:
+				// A previous failure is still unconsumed; drop this one
+				// rather than block the scheduler.
+			}
+		}
+	})
+	if err != nil {
+		return nil, serrors.WrapStr("parsing backup.schedule", err, "schedule", cfg.Backup.Schedule)
+	}
+	s.cron.Start()
+	return s, nil
+}
+
+// Errs returns the channel periodic backup failures are reported on. The
+// channel is buffered with capacity one; a failure that arrives while it
+// is still full is dropped.
+func (s *BackupScheduler) Errs() <-chan error {
+	return s.errs
+}
+
+// Stop stops the scheduler and waits for any in-flight tick to finish.
+func (s *BackupScheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Anapaya Systems
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truststorage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scionproto/scion/go/lib/truststorage"
+)
+
+func TestRunScheduledBackup(t *testing.T) {
+	ctx := context.Background()
+	cfg := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "trust.db"),
+		Backup:     truststorage.BackupConfig{Path: filepath.Join(t.TempDir(), "trust.bak")},
+	}
+	db, err := cfg.New()
+	require.NoError(t, err)
+	_, err = db.InsertTRC(ctx, "1", 1, []byte("trc-1-1"))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	require.NoError(t, cfg.RunScheduledBackup(ctx))
+
+	info, err := os.Stat(cfg.Backup.Path)
+	require.NoError(t, err)
+	assert.Greater(t, info.Size(), int64(0))
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(),
+		"backup file must not be group/world readable; it holds plaintext key material")
+}
+
+func TestRunScheduledBackupRequiresPath(t *testing.T) {
+	cfg := truststorage.Config{Backend: truststorage.BackendSqlite}
+	assert.Error(t, cfg.RunScheduledBackup(context.Background()))
+}
+
+func TestStartBackupSchedulerNoSchedule(t *testing.T) {
+	cfg := truststorage.Config{Backend: truststorage.BackendSqlite}
+	s, err := truststorage.StartBackupScheduler(&cfg)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestStartBackupSchedulerTicks(t *testing.T) {
+	ctx := context.Background()
+	cfg := truststorage.Config{
+		Backend:    truststorage.BackendSqlite,
+		Connection: filepath.Join(t.TempDir(), "trust.db"),
+		Backup: truststorage.BackupConfig{
+			Schedule: "@every 1s",
+			Path:     filepath.Join(t.TempDir(), "trust.bak"),
+		},
+	}
+	db, err := cfg.New()
+	require.NoError(t, err)
+	_, err = db.InsertTRC(ctx, "1", 1, []byte("trc-1-1"))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := truststorage.StartBackupScheduler(&cfg)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	defer s.Stop()
+
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(cfg.Backup.Path)
+		return err == nil && info.Size() > 0
+	}, 3*time.Second, 10*time.Millisecond)
+}